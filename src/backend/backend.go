@@ -0,0 +1,24 @@
+// Package backend defines the storage abstraction behind an image set:
+// something that can list file names, open one for reading, and notify a
+// watcher when the listing changes. localfs.go implements this against a
+// directory on disk (today's behavior); gdrive.go implements it against a
+// Google Drive folder.
+package backend
+
+import "io"
+
+// Backend is anything that can serve a flat collection of named files.
+type Backend interface {
+	// List returns the names of every file currently available.
+	List() []string
+	// Open returns a reader for the named file along with its content
+	// type. Callers must close the reader.
+	Open(name string) (io.ReadCloser, string, error)
+	// Stat reports the named file's size without opening it, so a caller
+	// can write something like a tar header before streaming the body.
+	// ok is false when the backend has no size on hand for name.
+	Stat(name string) (size int64, ok bool)
+	// Watch sends an (empty) notification on ch whenever the set of
+	// files may have changed, so the caller can re-List.
+	Watch(ch chan<- struct{})
+}
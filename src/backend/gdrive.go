@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// defaultPollInterval is used when GDrive.PollInterval is left at zero.
+const defaultPollInterval = 5 * time.Minute
+
+// GDrive is a Backend that lists and serves files out of a single Google
+// Drive folder, authenticated with an OAuth2 refresh token. It lets
+// garyapi host its image sets on Drive without changing the public
+// /gary, /goober or /*/image API shape.
+type GDrive struct {
+	FolderID     string
+	PollInterval time.Duration
+
+	svc *drive.Service
+
+	mu    sync.RWMutex
+	files map[string]driveFile // file name -> Drive file ID/size
+}
+
+// driveFile is what GDrive remembers about one Drive file between polls.
+type driveFile struct {
+	id   string
+	size int64
+}
+
+// NewGDrive builds a GDrive backend, exchanging clientID/clientSecret/
+// refreshToken for an authenticated Drive client and loading the initial
+// file listing for folderID.
+func NewGDrive(clientID, clientSecret, refreshToken, folderID string, pollInterval time.Duration) (*GDrive, error) {
+	conf := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     google.Endpoint,
+	}
+	client := conf.Client(context.Background(), &oauth2.Token{RefreshToken: refreshToken})
+
+	svc, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("could not create drive client: %w", err)
+	}
+
+	g := &GDrive{FolderID: folderID, PollInterval: pollInterval, svc: svc}
+	if err := g.refresh(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// refresh re-lists g.FolderID, excluding weightsFileName the same way
+// LocalFS.List does, so a weights.json sidecar uploaded alongside a
+// WeightedSelector's images isn't itself served as one. An error leaves
+// g.files untouched, so NewGDrive can tell bad credentials/folder IDs
+// from a working backend and the caller can fall back to LocalFS
+// instead of keeping a permanently-empty GDrive around.
+func (g *GDrive) refresh() error {
+	query := fmt.Sprintf("'%s' in parents and trashed = false", g.FolderID)
+	list, err := g.svc.Files.List().Q(query).Fields("files(id, name, size)").Do()
+	if err != nil {
+		return fmt.Errorf("could not list drive folder %s: %w", g.FolderID, err)
+	}
+
+	files := make(map[string]driveFile, len(list.Files))
+	for _, f := range list.Files {
+		if f.Name == weightsFileName {
+			continue
+		}
+		files[f.Name] = driveFile{id: f.Id, size: f.Size}
+	}
+
+	g.mu.Lock()
+	g.files = files
+	g.mu.Unlock()
+	return nil
+}
+
+// List returns the names of every file currently known in the Drive
+// folder.
+func (g *GDrive) List() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	names := make([]string, 0, len(g.files))
+	for name := range g.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Open downloads the named file's content from Drive.
+func (g *GDrive) Open(name string) (io.ReadCloser, string, error) {
+	g.mu.RLock()
+	file, ok := g.files[name]
+	g.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown drive file %s", name)
+	}
+
+	resp, err := g.svc.Files.Get(file.id).Download()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not download drive file %s: %w", name, err)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// Stat returns the size Drive reported for name as of the last refresh.
+func (g *GDrive) Stat(name string) (int64, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	file, ok := g.files[name]
+	return file.size, ok
+}
+
+// Watch polls the Drive folder on PollInterval (defaulting to five
+// minutes) and signals ch whenever the file listing actually changes -
+// not just its length, so one file being swapped for another of the same
+// name-count (a common way to rotate an image set) is still detected.
+func (g *GDrive) Watch(ch chan<- struct{}) {
+	interval := g.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			before := g.snapshot()
+			if err := g.refresh(); err != nil {
+				fmt.Printf("Error listing drive folder %s: %v\n", g.FolderID, err)
+				continue
+			}
+			after := g.snapshot()
+
+			if !sameFiles(before, after) {
+				ch <- struct{}{}
+			}
+		}
+	}()
+}
+
+// snapshot returns a copy of the current name -> Drive file map, safe to
+// use without holding g.mu.
+func (g *GDrive) snapshot() map[string]driveFile {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make(map[string]driveFile, len(g.files))
+	for name, file := range g.files {
+		out[name] = file
+	}
+	return out
+}
+
+// sameFiles reports whether a and b list exactly the same file names,
+// Drive IDs and sizes.
+func sameFiles(a, b map[string]driveFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, file := range a {
+		if b[name] != file {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// LocalFS is a Backend backed by a plain directory on disk, matching
+// garyapi's original behavior.
+type LocalFS struct {
+	Dir string
+}
+
+// weightsFileName is the WeightedSelector config sidecar. It lives
+// alongside the images it weights but isn't itself a selectable image.
+const weightsFileName = "weights.json"
+
+// List returns the names of the regular files directly inside Dir, other
+// than weightsFileName.
+func (l *LocalFS) List() []string {
+	files, err := os.ReadDir(l.Dir)
+	if err != nil {
+		fmt.Printf("Error reading dir %s: %v\n", l.Dir, err)
+		return nil
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && file.Name() != weightsFileName {
+			names = append(names, file.Name())
+		}
+	}
+	return names
+}
+
+// Open opens the named file under Dir.
+func (l *LocalFS) Open(name string) (io.ReadCloser, string, error) {
+	f, err := os.Open(filepath.Join(l.Dir, name))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open %s: %w", name, err)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return f, contentType, nil
+}
+
+// Stat returns the on-disk size of the named file under Dir.
+func (l *LocalFS) Stat(name string) (int64, bool) {
+	info, err := os.Stat(filepath.Join(l.Dir, name))
+	if err != nil {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// Watch starts an fsnotify watcher on Dir and signals ch on every
+// create/remove/rename event.
+func (l *LocalFS) Watch(ch chan<- struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Failed to create watcher for %s: %v\n", l.Dir, err)
+		return
+	}
+	if err := watcher.Add(l.Dir); err != nil {
+		fmt.Printf("Failed to watch directory %s: %v\n", l.Dir, err)
+		return
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					ch <- struct{}{}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("Watcher error for %s: %v\n", l.Dir, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,290 @@
+// Package cache implements a small on-disk response cache with TTL and
+// size-based eviction, loosely modeled on Hugo's consolidated filecache.
+//
+// Handlers don't need to know about the disk layout: they call
+// Cache.GetOrCreate with a key and a function that produces the value on a
+// miss, and the cache takes care of storing, expiring and evicting entries.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the settings for a single named cache, as loaded from the
+// cache config file.
+type Config struct {
+	// Dir is the directory entries are stored in. It supports the
+	// placeholders ":cacheDir" (a fixed "garyapi-cache" root under the
+	// OS temp dir, shared by every process) and ":tempDir" (os.TempDir()).
+	Dir string `json:"dir"`
+	// MaxAge is how long an entry stays valid, in seconds. -1 means
+	// "forever" (never expires), 0 means the cache is disabled and every
+	// GetOrCreate call falls through to its fn.
+	MaxAge int `json:"maxAge"`
+	// MaxSize caps the total number of bytes the cache directory may hold.
+	// Once exceeded, the least-recently-used entries are evicted until the
+	// cache is back under the limit. Zero means unbounded.
+	MaxSize int64 `json:"maxSize"`
+}
+
+// Configs maps a cache name (e.g. "quotes", "jokes", "gary-url") to its
+// Config.
+type Configs map[string]Config
+
+// LoadConfigFile reads a JSON file of the form {"name": {"dir": ..., ...}}
+// and expands directory placeholders.
+func LoadConfigFile(path string) (Configs, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cache config %s: %w", path, err)
+	}
+
+	var cfgs Configs
+	if err := json.Unmarshal(raw, &cfgs); err != nil {
+		return nil, fmt.Errorf("could not parse cache config %s: %w", path, err)
+	}
+
+	for name, cfg := range cfgs {
+		cfg.Dir = expandPlaceholders(cfg.Dir)
+		cfgs[name] = cfg
+	}
+	return cfgs, nil
+}
+
+func expandPlaceholders(dir string) string {
+	dir = strings.ReplaceAll(dir, ":tempDir", os.TempDir())
+	dir = strings.ReplaceAll(dir, ":cacheDir", filepath.Join(os.TempDir(), "garyapi-cache"))
+	return dir
+}
+
+// entryMeta is the JSON sidecar stored next to every cached entry.
+type entryMeta struct {
+	Expiry      int64  `json:"expiry"` // unix seconds; 0 means it never expires
+	ContentType string `json:"contentType"`
+}
+
+// Cache is a single named, disk-backed cache.
+type Cache struct {
+	name string
+	cfg  Config
+
+	// entryLocks holds one *sync.Mutex per key, created on first use
+	// (map[string]*sync.Mutex), so two requests for different keys never
+	// wait on each other - only concurrent requests for the exact same
+	// key serialize, which also keeps them from racing to call fn() and
+	// write the same entry twice. Entries are dropped via dropEntryLock
+	// whenever their on-disk file is removed (expiry, eviction, purge) or
+	// a miss ends with nothing stored, so this doesn't grow without bound
+	// as clients vary the cache key (e.g. a ?tag= query param).
+	entryLocks sync.Map
+	// dirMu guards operations that touch the whole cache directory
+	// (eviction, purge) against racing a concurrent entry write.
+	dirMu sync.Mutex
+}
+
+// lockEntry locks (creating if needed) the mutex for key and returns a
+// func to unlock it.
+func (c *Cache) lockEntry(key string) func() {
+	v, _ := c.entryLocks.LoadOrStore(key, &sync.Mutex{})
+	m := v.(*sync.Mutex)
+	m.Lock()
+	return m.Unlock
+}
+
+// dropEntryLock forgets key's mutex once its on-disk entry is gone, so a
+// client that keeps varying the cache key can't grow entryLocks forever.
+func (c *Cache) dropEntryLock(key string) {
+	c.entryLocks.Delete(key)
+}
+
+// Manager owns every named Cache configured for the process.
+type Manager struct {
+	mu     sync.RWMutex
+	caches map[string]*Cache
+}
+
+// NewManager builds a Manager from a set of named configs.
+func NewManager(cfgs Configs) *Manager {
+	m := &Manager{caches: make(map[string]*Cache, len(cfgs))}
+	for name, cfg := range cfgs {
+		m.caches[name] = &Cache{name: name, cfg: cfg}
+	}
+	return m
+}
+
+// Cache returns the named cache, or a disabled stand-in if it wasn't
+// configured. Callers can always use the result without a nil check.
+func (m *Manager) Cache(name string) *Cache {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c, ok := m.caches[name]; ok {
+		return c
+	}
+	return &Cache{name: name}
+}
+
+// GetOrCreate returns the cached value for key, calling fn and storing its
+// result on a miss or expiry. fn returns the value bytes and its content
+// type.
+func (c *Cache) GetOrCreate(key string, fn func() ([]byte, string, error)) ([]byte, string, error) {
+	if c.cfg.MaxAge == 0 || c.cfg.Dir == "" {
+		return fn()
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	dataPath := filepath.Join(c.cfg.Dir, name)
+	metaPath := dataPath + ".json"
+
+	// Only requests for the same key serialize here, so a hit (or a miss)
+	// on one key never waits behind unrelated keys in this cache.
+	unlock := c.lockEntry(name)
+	defer unlock()
+
+	if meta, ok := c.readMeta(metaPath); ok {
+		if data, err := os.ReadFile(dataPath); err == nil {
+			// Bump the mtime so evictIfOversized's sweep sees this entry
+			// as recently used.
+			now := time.Now()
+			_ = os.Chtimes(dataPath, now, now)
+			return data, meta.ContentType, nil
+		}
+	}
+
+	data, contentType, err := fn()
+	if err != nil {
+		c.dropEntryLock(name)
+		return nil, "", err
+	}
+
+	c.dirMu.Lock()
+	defer c.dirMu.Unlock()
+
+	if err := os.MkdirAll(c.cfg.Dir, 0o755); err != nil {
+		fmt.Printf("[cache:%s] could not create cache dir %s: %v\n", c.name, c.cfg.Dir, err)
+		c.dropEntryLock(name)
+		return data, contentType, nil
+	}
+	if err := os.WriteFile(dataPath, data, 0o644); err != nil {
+		fmt.Printf("[cache:%s] could not write entry %s: %v\n", c.name, dataPath, err)
+		c.dropEntryLock(name)
+		return data, contentType, nil
+	}
+
+	expiry := int64(0)
+	if c.cfg.MaxAge > 0 {
+		expiry = time.Now().Add(time.Duration(c.cfg.MaxAge) * time.Second).Unix()
+	}
+	meta := entryMeta{Expiry: expiry, ContentType: contentType}
+	if metaBytes, err := json.Marshal(meta); err == nil {
+		_ = os.WriteFile(metaPath, metaBytes, 0o644)
+	}
+
+	c.evictIfOversized()
+	return data, contentType, nil
+}
+
+func (c *Cache) readMeta(metaPath string) (entryMeta, bool) {
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return entryMeta{}, false
+	}
+	var meta entryMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return entryMeta{}, false
+	}
+	if meta.Expiry != 0 && time.Now().Unix() >= meta.Expiry {
+		dataPath := strings.TrimSuffix(metaPath, ".json")
+		os.Remove(dataPath)
+		os.Remove(metaPath)
+		c.dropEntryLock(filepath.Base(dataPath))
+		return entryMeta{}, false
+	}
+	return meta, true
+}
+
+// evictIfOversized sweeps the cache directory from least- to
+// most-recently-used, removing entries until the directory is back under
+// MaxSize. Must be called with c.dirMu held.
+func (c *Cache) evictIfOversized() {
+	if c.cfg.MaxSize <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return
+	}
+
+	type dataFile struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+	var files []dataFile
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		files = append(files, dataFile{
+			path:    filepath.Join(c.cfg.Dir, entry.Name()),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		})
+	}
+
+	if total <= c.cfg.MaxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.cfg.MaxSize {
+			break
+		}
+		os.Remove(f.path)
+		os.Remove(f.path + ".json")
+		c.dropEntryLock(filepath.Base(f.path))
+		total -= f.size
+	}
+}
+
+// Purge removes every entry in the cache.
+func (c *Cache) Purge() error {
+	if c.cfg.Dir == "" {
+		return nil
+	}
+	c.dirMu.Lock()
+	defer c.dirMu.Unlock()
+
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read cache dir %s: %w", c.cfg.Dir, err)
+	}
+	for _, entry := range entries {
+		if err := os.Remove(filepath.Join(c.cfg.Dir, entry.Name())); err != nil {
+			fmt.Printf("[cache:%s] could not remove %s: %v\n", c.name, entry.Name(), err)
+			continue
+		}
+		c.dropEntryLock(strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return nil
+}
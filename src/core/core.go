@@ -0,0 +1,117 @@
+// Package core holds the protocol-agnostic logic behind garyapi's image
+// endpoints: picking a random image out of a hot-reloadable set. The HTTP
+// (Gin), Gopher and Gemini front ends all call into this package so the
+// three protocols stay in sync without duplicating the selection logic.
+// Quote/joke selection lives in the sibling quotes package.
+package core
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"sync"
+
+	"github.com/Zach11111/garyapi/src/backend"
+)
+
+// ImageSet is a goroutine-safe, hot-reloadable list of image file names
+// backed by a backend.Backend (local disk, Google Drive, ...). It is the
+// shared state every protocol front end reads from.
+type ImageSet struct {
+	backend  backend.Backend
+	selector Selector
+
+	mu     sync.RWMutex
+	images []string
+}
+
+// NewImageSet lists b's current contents and starts watching it for
+// changes. selector controls how Pick chooses among them; a nil selector
+// falls back to UniformSelector.
+func NewImageSet(b backend.Backend, selector Selector) *ImageSet {
+	if selector == nil {
+		selector = UniformSelector{}
+	}
+	s := &ImageSet{backend: b, selector: selector, images: b.List()}
+
+	changes := make(chan struct{})
+	b.Watch(changes)
+	go func() {
+		for range changes {
+			names := b.List()
+			s.mu.Lock()
+			s.images = names
+			s.mu.Unlock()
+		}
+	}()
+
+	return s
+}
+
+// Snapshot returns a copy of the current file names, safe to use without
+// holding any lock.
+func (s *ImageSet) Snapshot() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, len(s.images))
+	copy(out, s.images)
+	return out
+}
+
+// Open opens the named file through the underlying backend. name must be
+// one of the names s currently lists (per Snapshot/Pick); this rejects
+// arbitrary names a caller might build from untrusted input, such as a
+// Gopher selector, before they ever reach the backend's path handling.
+// Callers must close the returned reader.
+func (s *ImageSet) Open(name string) (io.ReadCloser, string, error) {
+	if !contains(s.Snapshot(), name) {
+		return nil, "", fmt.Errorf("unknown image %q", name)
+	}
+	return s.backend.Open(name)
+}
+
+// Pick selects one image through s's configured Selector, falling back to
+// defaultName if no images are currently available.
+func (s *ImageSet) Pick(req SelectRequest, defaultName string) string {
+	return s.selector.Select(s.Snapshot(), defaultName, req)
+}
+
+// Stat reports the size of the named file, if the backend can report one
+// without opening it. ok is false when the backend has no size on hand.
+func (s *ImageSet) Stat(name string) (size int64, ok bool) {
+	return s.backend.Stat(name)
+}
+
+// Uniform reports whether s picks with plain uniform randomness, as
+// opposed to a mode (seeded, no-repeat, weighted) whose outcome depends on
+// the requesting client. Callers that cache a Pick result by request URL,
+// without the caller's identity, should skip the cache unless this is
+// true - otherwise every client replays whichever client picked first.
+func (s *ImageSet) Uniform() bool {
+	_, ok := s.selector.(UniformSelector)
+	return ok
+}
+
+// RandomImage picks a random file name out of images, or defaultName if
+// images is empty.
+func RandomImage(images []string, defaultName string) string {
+	if len(images) == 0 {
+		return defaultName
+	}
+	return images[rand.Intn(len(images))]
+}
+
+var numberRe = regexp.MustCompile(`\d+`)
+
+// ExtractNumber pulls the first run of digits out of filename, or 0 if
+// there isn't one.
+func ExtractNumber(filename string) int {
+	match := numberRe.FindString(filename)
+	if match == "" {
+		return 0
+	}
+	var number int
+	fmt.Sscanf(match, "%d", &number)
+	return number
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SelectRequest carries the per-request inputs a Selector may need beyond
+// the image list itself: an HTTP client's ?seed= (for SeededSelector) and
+// a stable per-client identifier, typically a cookie or X-Client-Id
+// header (for NoRepeatSelector).
+type SelectRequest struct {
+	Seed     int64
+	ClientID string
+}
+
+// Selector picks one file name out of images, falling back to
+// defaultName when images is empty.
+type Selector interface {
+	Select(images []string, defaultName string, req SelectRequest) string
+}
+
+// UniformSelector picks uniformly at random. It's RandomImage's original
+// behavior, wrapped up so it can be swapped out per endpoint.
+type UniformSelector struct{}
+
+// Select implements Selector.
+func (UniformSelector) Select(images []string, defaultName string, _ SelectRequest) string {
+	return RandomImage(images, defaultName)
+}
+
+// SeededSelector picks deterministically from req.Seed, so the same seed
+// always yields the same file name for a given image list.
+type SeededSelector struct{}
+
+// Select implements Selector.
+func (SeededSelector) Select(images []string, defaultName string, req SelectRequest) string {
+	if len(images) == 0 {
+		return defaultName
+	}
+	r := rand.New(rand.NewSource(req.Seed))
+	return images[r.Intn(len(images))]
+}
+
+// maxTrackedClients bounds NoRepeatSelector's per-client state so an
+// endless stream of distinct client IDs can't grow it without limit; the
+// least-recently-seen client is evicted once the limit is hit.
+const maxTrackedClients = 10000
+
+// NoRepeatSelector avoids returning any of a client's last K picks until
+// the ring wraps (or there are K images or fewer, in which case a repeat
+// is unavoidable once all of them are among the last K, and allowed).
+type NoRepeatSelector struct {
+	K int
+
+	mu      sync.Mutex
+	order   []string // client IDs, least-recently-seen first
+	history map[string][]string
+}
+
+// NewNoRepeatSelector builds a NoRepeatSelector that tracks the last k
+// picks per client.
+func NewNoRepeatSelector(k int) *NoRepeatSelector {
+	return &NoRepeatSelector{K: k, history: make(map[string][]string)}
+}
+
+// Select implements Selector.
+func (s *NoRepeatSelector) Select(images []string, defaultName string, req SelectRequest) string {
+	if len(images) == 0 {
+		return defaultName
+	}
+	if req.ClientID == "" || s.K <= 0 {
+		return RandomImage(images, defaultName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recent := s.history[req.ClientID]
+	candidates := make([]string, 0, len(images))
+	for _, name := range images {
+		if !contains(recent, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = images
+	}
+
+	pick := candidates[rand.Intn(len(candidates))]
+	s.remember(req.ClientID, pick)
+	return pick
+}
+
+func (s *NoRepeatSelector) remember(clientID, name string) {
+	if _, seen := s.history[clientID]; seen {
+		// Move clientID to the most-recently-seen end of order.
+		for i, id := range s.order {
+			if id == clientID {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.order = append(s.order, clientID)
+	if len(s.order) > maxTrackedClients {
+		evict := s.order[0]
+		s.order = s.order[1:]
+		delete(s.history, evict)
+	}
+
+	recent := append(s.history[clientID], name)
+	if len(recent) > s.K {
+		recent = recent[len(recent)-s.K:]
+	}
+	s.history[clientID] = recent
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WeightedSelector samples images according to a float weight per file
+// name, loaded from a weights.json sibling in Dir. The parsed weights are
+// cached and only re-read when the file's mtime changes, so a normal
+// request doesn't pay for a disk read and JSON parse. A missing file, or
+// a name absent from weights.json, falls back to a weight of 1; a parse
+// error keeps the last weights that loaded (weight 1 for every name if
+// none have). A name explicitly weighted at 0 (or negative) is never
+// picked, unless every name is weighted 0, in which case Select picks
+// uniformly.
+type WeightedSelector struct {
+	Dir string
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	weights  map[string]float64
+}
+
+// NewWeightedSelector builds a WeightedSelector reading weights.json out
+// of dir.
+func NewWeightedSelector(dir string) *WeightedSelector {
+	return &WeightedSelector{Dir: dir}
+}
+
+// Select implements Selector.
+func (s *WeightedSelector) Select(images []string, defaultName string, _ SelectRequest) string {
+	if len(images) == 0 {
+		return defaultName
+	}
+	weights := s.currentWeights()
+
+	cumulative := make([]float64, len(images))
+	var total float64
+	for i, name := range images {
+		w, ok := weights[name]
+		if !ok {
+			w = 1
+		} else if w < 0 {
+			w = 0
+		}
+		total += w
+		cumulative[i] = total
+	}
+	if total <= 0 {
+		return RandomImage(images, defaultName)
+	}
+
+	target := rand.Float64() * total
+	i := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > target })
+	if i >= len(images) {
+		i = len(images) - 1
+	}
+	return images[i]
+}
+
+// currentWeights returns the cached weights map, reloading it first if
+// weights.json has changed since the last load.
+func (s *WeightedSelector) currentWeights() map[string]float64 {
+	path := filepath.Join(s.Dir, "weights.json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.ModTime().After(s.loadedAt) || s.weights == nil {
+		weights, err := loadWeights(path)
+		if err != nil {
+			fmt.Printf("Could not load %s: %v\n", path, err)
+			return s.weights
+		}
+		s.weights = weights
+		s.loadedAt = info.ModTime()
+	}
+	return s.weights
+}
+
+func loadWeights(path string) (map[string]float64, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var weights map[string]float64
+	if err := json.Unmarshal(raw, &weights); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// ParseSelector builds the Selector named by raw: "uniform" (the
+// default), "seeded", "norepeat:K" (K defaults to 20 if omitted or
+// invalid), or "weighted". dir is passed through to WeightedSelector.
+// Intended to be read straight from an env var, e.g.
+// GARY_SELECTOR=norepeat:50.
+func ParseSelector(raw, dir string) Selector {
+	name, arg, _ := strings.Cut(raw, ":")
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "seeded":
+		return SeededSelector{}
+	case "norepeat":
+		k := 20
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			k = n
+		}
+		return NewNoRepeatSelector(k)
+	case "weighted":
+		return NewWeightedSelector(dir)
+	default:
+		return UniformSelector{}
+	}
+}
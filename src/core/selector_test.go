@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUniformSelectorEmpty(t *testing.T) {
+	got := UniformSelector{}.Select(nil, "default.jpg", SelectRequest{})
+	if got != "default.jpg" {
+		t.Errorf("Select(nil) = %q, want default.jpg", got)
+	}
+}
+
+func TestUniformSelectorPicksFromImages(t *testing.T) {
+	images := []string{"a.jpg", "b.jpg", "c.jpg"}
+	got := UniformSelector{}.Select(images, "default.jpg", SelectRequest{})
+	if !contains(images, got) {
+		t.Errorf("Select(%v) = %q, want one of them", images, got)
+	}
+}
+
+func TestSeededSelectorDeterministic(t *testing.T) {
+	images := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}
+	req := SelectRequest{Seed: 42}
+
+	first := SeededSelector{}.Select(images, "default.jpg", req)
+	for i := 0; i < 5; i++ {
+		got := SeededSelector{}.Select(images, "default.jpg", req)
+		if got != first {
+			t.Errorf("Select with Seed=42 returned %q on call %d, want %q every time", got, i, first)
+		}
+	}
+}
+
+func TestSeededSelectorVariesWithSeed(t *testing.T) {
+	images := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg"}
+	a := SeededSelector{}.Select(images, "default.jpg", SelectRequest{Seed: 1})
+	b := SeededSelector{}.Select(images, "default.jpg", SelectRequest{Seed: 2})
+	if a == b {
+		t.Skip("different seeds happened to collide on the same image; not a failure by itself")
+	}
+}
+
+func TestSeededSelectorEmpty(t *testing.T) {
+	got := SeededSelector{}.Select(nil, "default.jpg", SelectRequest{Seed: 1})
+	if got != "default.jpg" {
+		t.Errorf("Select(nil) = %q, want default.jpg", got)
+	}
+}
+
+func TestNoRepeatSelectorAvoidsRecentPicks(t *testing.T) {
+	images := []string{"a.jpg", "b.jpg", "c.jpg"}
+	sel := NewNoRepeatSelector(2)
+	req := SelectRequest{ClientID: "client-1"}
+
+	seen := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		pick := sel.Select(images, "default.jpg", req)
+		if len(seen) >= 2 && (pick == seen[len(seen)-1] || pick == seen[len(seen)-2]) {
+			t.Errorf("pick %d (%q) repeats one of the last 2 picks %v", i, pick, seen[len(seen)-2:])
+		}
+		seen = append(seen, pick)
+	}
+}
+
+func TestNoRepeatSelectorTracksClientsIndependently(t *testing.T) {
+	images := []string{"a.jpg", "b.jpg"}
+	sel := NewNoRepeatSelector(1)
+
+	firstForA := sel.Select(images, "default.jpg", SelectRequest{ClientID: "a"})
+	sel.Select(images, "default.jpg", SelectRequest{ClientID: "b"})
+	secondForA := sel.Select(images, "default.jpg", SelectRequest{ClientID: "a"})
+
+	if firstForA == secondForA {
+		t.Errorf("client a got %q twice in a row out of only 2 images with K=1, want the other image", firstForA)
+	}
+}
+
+func TestNoRepeatSelectorWithoutClientIDFallsBackToRandom(t *testing.T) {
+	images := []string{"a.jpg"}
+	sel := NewNoRepeatSelector(5)
+	got := sel.Select(images, "default.jpg", SelectRequest{})
+	if got != "a.jpg" {
+		t.Errorf("Select with no ClientID = %q, want a.jpg", got)
+	}
+}
+
+func TestWeightedSelectorHonorsWeights(t *testing.T) {
+	dir := t.TempDir()
+	weights := map[string]float64{"a.jpg": 0, "b.jpg": 1}
+	raw, err := json.Marshal(weights)
+	if err != nil {
+		t.Fatalf("could not marshal weights: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "weights.json"), raw, 0o644); err != nil {
+		t.Fatalf("could not write weights.json: %v", err)
+	}
+
+	sel := NewWeightedSelector(dir)
+	images := []string{"a.jpg", "b.jpg"}
+	for i := 0; i < 20; i++ {
+		got := sel.Select(images, "default.jpg", SelectRequest{})
+		if got != "b.jpg" {
+			t.Fatalf("Select() = %q, want b.jpg every time since a.jpg is weighted 0", got)
+		}
+	}
+}
+
+func TestWeightedSelectorMissingFileFallsBackToUniform(t *testing.T) {
+	sel := NewWeightedSelector(t.TempDir())
+	images := []string{"a.jpg", "b.jpg"}
+	got := sel.Select(images, "default.jpg", SelectRequest{})
+	if !contains(images, got) {
+		t.Errorf("Select() = %q with no weights.json, want one of %v", got, images)
+	}
+}
+
+func TestWeightedSelectorEmpty(t *testing.T) {
+	sel := NewWeightedSelector(t.TempDir())
+	got := sel.Select(nil, "default.jpg", SelectRequest{})
+	if got != "default.jpg" {
+		t.Errorf("Select(nil) = %q, want default.jpg", got)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, ok := ParseSelector("", dir).(UniformSelector); !ok {
+		t.Errorf("ParseSelector(%q) did not return a UniformSelector", "")
+	}
+	if _, ok := ParseSelector("uniform", dir).(UniformSelector); !ok {
+		t.Errorf("ParseSelector(%q) did not return a UniformSelector", "uniform")
+	}
+	if _, ok := ParseSelector("seeded", dir).(SeededSelector); !ok {
+		t.Errorf("ParseSelector(%q) did not return a SeededSelector", "seeded")
+	}
+	if _, ok := ParseSelector("norepeat:5", dir).(*NoRepeatSelector); !ok {
+		t.Errorf("ParseSelector(%q) did not return a *NoRepeatSelector", "norepeat:5")
+	}
+	if _, ok := ParseSelector("weighted", dir).(*WeightedSelector); !ok {
+		t.Errorf("ParseSelector(%q) did not return a *WeightedSelector", "weighted")
+	}
+}
@@ -1,172 +1,323 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/subtle"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"os"
-	"path/filepath"
-	"regexp"
 	"runtime"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+
+	"github.com/Zach11111/garyapi/src/backend"
+	"github.com/Zach11111/garyapi/src/cache"
+	"github.com/Zach11111/garyapi/src/core"
+	"github.com/Zach11111/garyapi/src/protocols"
+	"github.com/Zach11111/garyapi/src/quotes"
+	"github.com/Zach11111/garyapi/src/render"
 )
 
 const (
 	defaultGaryImg   = "Gary76.jpg"
 	defaultGooberImg = "goober8.jpg"
-)
 
-var (
-	garyImages   []string
-	gooberImages []string
-	imageCacheMu sync.RWMutex
+	// maxTarCount caps how many images a single ?format=tar request can
+	// bundle, so a client can't ask for an unbounded archive.
+	maxTarCount = 50
 )
 
-func cacheFileNames(dirPath string) []string {
-	files, err := os.ReadDir(dirPath)
-	if err != nil {
-		fmt.Printf("Error reading dir %s: %v\n", dirPath, err)
-		return nil
+// cacheKey builds a cache lookup key that varies with the negotiated
+// format, since c.Request.URL.String() alone doesn't capture a format
+// chosen via the Accept header rather than ?format=.
+func cacheKey(c *gin.Context, format render.Format) string {
+	return c.Request.URL.String() + "|" + string(format)
+}
+
+// selectRequestFromContext reads the inputs the seeded and no-repeat
+// selectors need out of c: ?seed=, and a client ID from the X-Client-Id
+// header or, failing that, a "client_id" cookie.
+func selectRequestFromContext(c *gin.Context) core.SelectRequest {
+	var seed int64
+	if raw := c.Query("seed"); raw != "" {
+		seed, _ = strconv.ParseInt(raw, 10, 64)
 	}
+	clientID := c.GetHeader("X-Client-Id")
+	if clientID == "" {
+		clientID, _ = c.Cookie("client_id")
+	}
+	return core.SelectRequest{Seed: seed, ClientID: clientID}
+}
 
-	names := make([]string, 0, len(files))
-	for _, file := range files {
-		if !file.IsDir() {
-			names = append(names, file.Name())
+func serveRandomImageHandler(images *core.ImageSet, defaultImage string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		imageName := images.Pick(selectRequestFromContext(c), defaultImage)
+		reader, contentType, err := images.Open(imageName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		defer reader.Close()
+		c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
 	}
-	return names
 }
 
-func getRandomFileName(images []string, defaultName string) string {
-	if len(images) == 0 {
-		return defaultName
+func serveImageURLHandler(baseURL string, images *core.ImageSet, defaultImage string, imageCache *cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := render.Negotiate(c)
+		if format == render.Tar {
+			serveImageTarHandler(c, images, defaultImage)
+			return
+		}
+
+		pick := func() ([]byte, string, error) {
+			imageName := images.Pick(selectRequestFromContext(c), defaultImage)
+			data := render.ImageURL{
+				URL:    fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/"), imageName),
+				Number: core.ExtractNumber(imageName),
+			}
+			return data.Render(format)
+		}
+
+		// A cached hit replays whatever image an earlier request picked,
+		// regardless of this request's seed or client ID, so non-uniform
+		// selector modes (seeded, no-repeat, weighted) bypass the cache
+		// entirely and always apply the selector to this request.
+		var body []byte
+		var contentType string
+		var err error
+		if images.Uniform() {
+			body, contentType, err = imageCache.GetOrCreate(cacheKey(c, format), pick)
+		} else {
+			body, contentType, err = pick()
+		}
+		if err != nil {
+			c.JSON(render.StatusForError(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, contentType, body)
 	}
-	return images[rand.Intn(len(images))]
 }
 
-func getRandomLineFromFile(filePath string) (string, error) {
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", fmt.Errorf("could not read file %s: %w", filePath, err)
+// serveImageTarHandler streams ?count= (capped at maxTarCount) random
+// images as a single uncached tar archive.
+func serveImageTarHandler(c *gin.Context, images *core.ImageSet, defaultImage string) {
+	count := 1
+	if raw := c.Query("count"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			count = n
+		}
+	}
+	if count < 1 {
+		count = 1
+	} else if count > maxTarCount {
+		count = maxTarCount
 	}
 
-	var lines []string
-	err = json.Unmarshal(fileContent, &lines)
-	if err != nil {
-		return "", fmt.Errorf("could not unmarshal JSON from %s: %w", filePath, err)
+	req := selectRequestFromContext(c)
+	names := make([]string, count)
+	for i := range names {
+		// SeededSelector derives one fixed index from req.Seed, so reusing
+		// req unchanged would pick the same image count times; offsetting
+		// the seed per entry keeps the archive deterministic while still
+		// varying what it contains.
+		itemReq := req
+		itemReq.Seed += int64(i)
+		names[i] = images.Pick(itemReq, defaultImage)
 	}
 
-	if len(lines) == 0 {
-		return "", fmt.Errorf("no lines found in %s", filePath)
+	err := render.StreamTar(c, names, func(name string) (io.ReadCloser, error) {
+		reader, _, err := images.Open(name)
+		return reader, err
+	}, images.Stat)
+	if err != nil {
+		fmt.Printf("tar stream failed: %v\n", err)
 	}
-	return lines[rand.Intn(len(lines))], nil
 }
 
-func extractNumberFromFilename(filename string) int {
-	re := regexp.MustCompile(`\d+`)
-	match := re.FindString(filename)
-	if match == "" {
-		return 0
+func serveRandomLineHandler(key string, store *quotes.Store, lineCache *cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("no %s store configured", key)})
+			return
+		}
+
+		format := render.Negotiate(c)
+		body, contentType, err := lineCache.GetOrCreate(cacheKey(c, format), func() ([]byte, string, error) {
+			entry, err := store.Random(c.Query("tag"))
+			if err != nil {
+				return nil, "", err
+			}
+			return render.Line{Key: key, Text: entry.Text}.Render(format)
+		})
+		if err != nil {
+			c.JSON(render.StatusForError(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, contentType, body)
 	}
-	var number int
-	fmt.Sscanf(match, "%d", &number)
-	return number
 }
 
-func serveRandomImageHandler(images *[]string, defaultImage, imageDir string) gin.HandlerFunc {
+// adminAuth gates an admin route behind an X-Admin-Token header matching
+// token (ADMIN_TOKEN). An unset token refuses every request, so an admin
+// route is unreachable by default rather than left open.
+func adminAuth(token string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Cache-Control", "no-store")
-		imageCacheMu.RLock()
-		imageName := getRandomFileName(*images, defaultImage)
-		imageCacheMu.RUnlock()
-		c.File(filepath.Join(imageDir, imageName))
+		if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
 	}
 }
 
-func serveImageURLHandler(baseURL, imageDir string, images *[]string, defaultImage string) gin.HandlerFunc {
+// serveCountHandler reports the result of count, honoring the negotiated
+// format.
+func serveCountHandler(count func() int) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		imageCacheMu.RLock()
-		imageName := getRandomFileName(*images, defaultImage)
-		imageCacheMu.RUnlock()
-
-		number := extractNumberFromFilename(imageName)
-
-		cleanBaseURL := baseURL
-		if len(cleanBaseURL) > 0 && cleanBaseURL[len(cleanBaseURL)-1] == '/' {
-			cleanBaseURL = cleanBaseURL[:len(cleanBaseURL)-1]
+		body, contentType, err := render.Count{Value: count()}.Render(render.Negotiate(c))
+		if err != nil {
+			c.JSON(render.StatusForError(err), gin.H{"error": err.Error()})
+			return
 		}
-		url := fmt.Sprintf("%s/%s", cleanBaseURL, imageName)
+		c.Data(http.StatusOK, contentType, body)
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"url":    url,
-			"number": number,
-		})
+// serveLineCountHandler reports how many entries are currently loaded in
+// store.
+func serveLineCountHandler(store *quotes.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no store configured"})
+			return
+		}
+		serveCountHandler(store.Count)(c)
 	}
 }
 
-func serveRandomLineHandler(filePath string) gin.HandlerFunc {
+// serveLineByIDHandler returns the key'd entry at the :id path param, in
+// the store's load order.
+func serveLineByIDHandler(key string, store *quotes.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		line, err := getRandomLineFromFile(filePath)
+		if store == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("no %s store configured", key)})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid id %q", c.Param("id"))})
 			return
 		}
-
-		var key string
-		switch filepath.Base(filePath) {
-		case filepath.Base(os.Getenv("QUOTES_FILE")):
-			key = "quote"
-		case filepath.Base(os.Getenv("JOKES_FILE")):
-			key = "joke"
-		default:
-			key = "line"
+		entry, err := store.ByID(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{key: entry.Text})
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{key: line})
+// loadQuoteStore loads a quotes.Store for path. A blank path (env var
+// unset) or a load failure disables the store: affected handlers report
+// an error per-request instead of failing startup.
+func loadQuoteStore(path string) *quotes.Store {
+	if path == "" {
+		return nil
+	}
+	store, err := quotes.NewStore(path)
+	if err != nil {
+		fmt.Printf("Quote store disabled for %s: %v\n", path, err)
+		return nil
 	}
+	return store
 }
 
-func startDirectoryWatcher(dir string, cache *[]string, label string) {
-	watcher, err := fsnotify.NewWatcher()
+// loadCacheManager loads the cache config from configPath, if set, and
+// returns a Manager for it. A missing or unset path yields a Manager with
+// no configured caches, so every Cache() lookup falls through to the
+// wrapped handler (disabled caching).
+func loadCacheManager(configPath string) *cache.Manager {
+	if configPath == "" {
+		return cache.NewManager(nil)
+	}
+	cfgs, err := cache.LoadConfigFile(configPath)
 	if err != nil {
-		fmt.Printf("Failed to create watcher for %s: %v\n", label, err)
-		return
+		fmt.Printf("Cache config disabled: %v\n", err)
+		return cache.NewManager(nil)
 	}
-	err = watcher.Add(dir)
+	return cache.NewManager(cfgs)
+}
+
+// newImageBackend picks a gdrive backend when a Drive folder is
+// configured for this image set's env prefix (e.g. "GARY" ->
+// GARY_GDRIVE_FOLDER_ID), falling back to the local directory otherwise.
+func newImageBackend(envPrefix, dir string) backend.Backend {
+	folderID := os.Getenv(envPrefix + "_GDRIVE_FOLDER_ID")
+	if folderID == "" {
+		return &backend.LocalFS{Dir: dir}
+	}
+
+	pollInterval := 5 * time.Minute
+	if raw := os.Getenv("GDRIVE_POLL_INTERVAL"); raw != "" {
+		if seconds, err := time.ParseDuration(raw + "s"); err == nil {
+			pollInterval = seconds
+		}
+	}
+
+	g, err := backend.NewGDrive(
+		os.Getenv("GDRIVE_CLIENT_ID"),
+		os.Getenv("GDRIVE_CLIENT_SECRET"),
+		os.Getenv("GDRIVE_REFRESH_TOKEN"),
+		folderID,
+		pollInterval,
+	)
 	if err != nil {
-		fmt.Printf("Failed to watch directory %s: %v\n", dir, err)
-		return
-	}
-
-	go func() {
-		defer watcher.Close()
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
-					imageCacheMu.Lock()
-					*cache = cacheFileNames(dir)
-					imageCacheMu.Unlock()
-					fmt.Printf("[%s] Cache updated due to event: %s\n", label, event)
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				fmt.Printf("[%s] Watcher error: %v\n", label, err)
+		fmt.Printf("Falling back to local dir for %s, gdrive backend failed: %v\n", envPrefix, err)
+		return &backend.LocalFS{Dir: dir}
+	}
+	return g
+}
+
+// startProtocolServers brings up the optional Gopher and Gemini front ends
+// configured via GOPHER_PORT / GEMINI_PORT. Both share the same ImageSets
+// and quote/joke files as the HTTP API.
+func startProtocolServers(garySet, gooberSet *core.ImageSet, quotesStore, jokesStore *quotes.Store) {
+	endpoints := protocols.Endpoints{
+		GaryImages:   garySet,
+		GooberImages: gooberSet,
+		QuotesStore:  quotesStore,
+		JokesStore:   jokesStore,
+	}
+
+	if port := os.Getenv("GOPHER_PORT"); port != "" {
+		server := &protocols.GopherServer{Endpoints: endpoints, Port: port}
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				fmt.Printf("Gopher server stopped: %v\n", err)
 			}
+		}()
+	}
+
+	if port := os.Getenv("GEMINI_PORT"); port != "" {
+		server := &protocols.GeminiServer{
+			Endpoints: endpoints,
+			Port:      port,
+			CertFile:  os.Getenv("GEMINI_CERT"),
+			KeyFile:   os.Getenv("GEMINI_KEY"),
 		}
-	}()
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				fmt.Printf("Gemini server stopped: %v\n", err)
+			}
+		}()
+	}
 }
 
 func main() {
@@ -182,19 +333,26 @@ func main() {
 	quotesPath := os.Getenv("QUOTES_FILE")
 	jokesPath := os.Getenv("JOKES_FILE")
 
-	garyImages = cacheFileNames(garyDir)
-	gooberImages = cacheFileNames(gooberDir)
+	cacheMgr := loadCacheManager(os.Getenv("CACHE_CONFIG"))
+
+	garySet := core.NewImageSet(newImageBackend("GARY", garyDir), core.ParseSelector(os.Getenv("GARY_SELECTOR"), garyDir))
+	gooberSet := core.NewImageSet(newImageBackend("GOOBER", gooberDir), core.ParseSelector(os.Getenv("GOOBER_SELECTOR"), gooberDir))
+	quotesStore := loadQuoteStore(quotesPath)
+	jokesStore := loadQuoteStore(jokesPath)
 
-	startDirectoryWatcher(garyDir, &garyImages, "Gary")
-	startDirectoryWatcher(gooberDir, &gooberImages, "Goober")
+	startProtocolServers(garySet, gooberSet, quotesStore, jokesStore)
 
-	r.Static("/Gary", garyDir)
-	r.Static("/Goober", gooberDir)
+	if garyDir != "" {
+		r.Static("/Gary", garyDir)
+	}
+	if gooberDir != "" {
+		r.Static("/Goober", gooberDir)
+	}
 
 	imageRoutes := r.Group("/")
 	{
-		imageRoutes.GET("/gary/image/*path", serveRandomImageHandler(&garyImages, defaultGaryImg, garyDir))
-		imageRoutes.GET("/goober/image/*path", serveRandomImageHandler(&gooberImages, defaultGooberImg, gooberDir))
+		imageRoutes.GET("/gary/image/*path", serveRandomImageHandler(garySet, defaultGaryImg))
+		imageRoutes.GET("/goober/image/*path", serveRandomImageHandler(gooberSet, defaultGooberImg))
 	}
 
 	apiRoutes := r.Group("/")
@@ -202,25 +360,27 @@ func main() {
 		garyBaseURL := os.Getenv("GARYURL")
 		gooberBaseURL := os.Getenv("GOOBERURL")
 
-		apiRoutes.GET("/gary", serveImageURLHandler(garyBaseURL, garyDir, &garyImages, defaultGaryImg))
-		apiRoutes.GET("/goober", serveImageURLHandler(gooberBaseURL, gooberDir, &gooberImages, defaultGooberImg))
-		apiRoutes.GET("/quote", serveRandomLineHandler(quotesPath))
-		apiRoutes.GET("/joke", serveRandomLineHandler(jokesPath))
-
-		apiRoutes.GET("/gary/count", func(c *gin.Context) {
-			imageCacheMu.RLock()
-			count := len(garyImages)
-			imageCacheMu.RUnlock()
-			c.JSON(http.StatusOK, gin.H{"count": count})
-		})
-		apiRoutes.GET("/goober/count", func(c *gin.Context) {
-			imageCacheMu.RLock()
-			count := len(gooberImages)
-			imageCacheMu.RUnlock()
-			c.JSON(http.StatusOK, gin.H{"count": count})
-		})
+		apiRoutes.GET("/gary", serveImageURLHandler(garyBaseURL, garySet, defaultGaryImg, cacheMgr.Cache("gary-url")))
+		apiRoutes.GET("/goober", serveImageURLHandler(gooberBaseURL, gooberSet, defaultGooberImg, cacheMgr.Cache("goober-url")))
+		apiRoutes.GET("/quote", serveRandomLineHandler("quote", quotesStore, cacheMgr.Cache("quotes")))
+		apiRoutes.GET("/joke", serveRandomLineHandler("joke", jokesStore, cacheMgr.Cache("jokes")))
+		apiRoutes.GET("/quote/:id", serveLineByIDHandler("quote", quotesStore))
+		apiRoutes.GET("/joke/:id", serveLineByIDHandler("joke", jokesStore))
+
+		apiRoutes.GET("/gary/count", serveCountHandler(func() int { return len(garySet.Snapshot()) }))
+		apiRoutes.GET("/goober/count", serveCountHandler(func() int { return len(gooberSet.Snapshot()) }))
+		apiRoutes.GET("/quote/count", serveLineCountHandler(quotesStore))
+		apiRoutes.GET("/joke/count", serveLineCountHandler(jokesStore))
 	}
 
+	r.POST("/admin/cache/purge/:name", adminAuth(os.Getenv("ADMIN_TOKEN")), func(c *gin.Context) {
+		if err := cacheMgr.Cache(c.Param("name")).Purge(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"purged": c.Param("name")})
+	})
+
 	indexFile := os.Getenv("INDEX_FILE")
 	if indexFile != "" {
 		r.GET("/", func(c *gin.Context) {
@@ -0,0 +1,18 @@
+// Package protocols exposes the gary/goober/quote/joke endpoints over
+// Gopher (RFC 1436) and Gemini, on top of the same core.ImageSet and
+// quotes.Store state the HTTP API uses.
+package protocols
+
+import (
+	"github.com/Zach11111/garyapi/src/core"
+	"github.com/Zach11111/garyapi/src/quotes"
+)
+
+// Endpoints bundles the state the Gopher and Gemini front ends need to
+// serve gary/goober/quote/joke requests, mirroring what main.go wires up
+// for the HTTP API. QuotesStore/JokesStore are nil when the corresponding
+// env var wasn't set or the file failed to load.
+type Endpoints struct {
+	GaryImages, GooberImages *core.ImageSet
+	QuotesStore, JokesStore  *quotes.Store
+}
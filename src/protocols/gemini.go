@@ -0,0 +1,121 @@
+package protocols
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/Zach11111/garyapi/src/core"
+	"github.com/Zach11111/garyapi/src/quotes"
+)
+
+// GeminiServer serves the gary/goober/quote/joke endpoints over
+// gemini://, using the standard "<status> <meta>\r\n" response header
+// followed by the body.
+type GeminiServer struct {
+	Endpoints
+	Port     string
+	CertFile string
+	KeyFile  string
+}
+
+// ListenAndServe accepts Gemini (TLS) connections until the listener
+// errors. A cert/key pair is required, per the Gemini spec's
+// TLS-only transport.
+func (s *GeminiServer) ListenAndServe() error {
+	if s.CertFile == "" || s.KeyFile == "" {
+		return fmt.Errorf("GEMINI_CERT and GEMINI_KEY are required to start the gemini server")
+	}
+	cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	if err != nil {
+		return fmt.Errorf("could not load gemini cert/key: %w", err)
+	}
+
+	ln, err := tls.Listen("tcp", ":"+s.Port, &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return fmt.Errorf("could not listen on gemini port %s: %w", s.Port, err)
+	}
+	defer ln.Close()
+	fmt.Printf("Gemini server listening on :%s\n", s.Port)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *GeminiServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	requestURL, err := url.Parse(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		fmt.Fprint(conn, "59 bad request\r\n")
+		return
+	}
+
+	switch requestURL.Path {
+	case "/", "":
+		s.writeMenu(conn)
+	case "/quote":
+		s.writeLine(conn, s.QuotesStore)
+	case "/joke":
+		s.writeLine(conn, s.JokesStore)
+	case "/gary/image":
+		s.writeImage(conn, s.GaryImages)
+	case "/goober/image":
+		s.writeImage(conn, s.GooberImages)
+	default:
+		fmt.Fprint(conn, "51 not found\r\n")
+	}
+}
+
+func (s *GeminiServer) writeMenu(conn net.Conn) {
+	fmt.Fprint(conn, "20 text/gemini\r\n")
+	fmt.Fprint(conn, "# garyapi\r\n")
+	fmt.Fprint(conn, "=> /gary/image A random Gary\r\n")
+	fmt.Fprint(conn, "=> /goober/image A random Goober\r\n")
+	fmt.Fprint(conn, "=> /quote A random quote\r\n")
+	fmt.Fprint(conn, "=> /joke A random joke\r\n")
+}
+
+func (s *GeminiServer) writeLine(conn net.Conn, store *quotes.Store) {
+	if store == nil {
+		fmt.Fprint(conn, "40 no entries available\r\n")
+		return
+	}
+	entry, err := store.Random("")
+	if err != nil {
+		fmt.Fprintf(conn, "40 %v\r\n", err)
+		return
+	}
+	fmt.Fprint(conn, "20 text/gemini\r\n")
+	fmt.Fprintf(conn, "%s\n", entry.Text)
+}
+
+func (s *GeminiServer) writeImage(conn net.Conn, images *core.ImageSet) {
+	imageName := images.Pick(core.SelectRequest{}, "")
+	if imageName == "" {
+		fmt.Fprint(conn, "40 no images available\r\n")
+		return
+	}
+	reader, contentType, err := images.Open(imageName)
+	if err != nil {
+		fmt.Fprintf(conn, "40 could not read %s: %v\r\n", imageName, err)
+		return
+	}
+	defer reader.Close()
+
+	fmt.Fprintf(conn, "20 %s\r\n", contentType)
+	_, _ = io.Copy(conn, reader)
+}
@@ -0,0 +1,118 @@
+package protocols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/Zach11111/garyapi/src/core"
+	"github.com/Zach11111/garyapi/src/quotes"
+)
+
+// GopherServer serves the gary/goober/quote/joke endpoints over a plain
+// RFC 1436 Gopher connection: the client sends a selector line, the server
+// writes a response and closes the connection.
+type GopherServer struct {
+	Endpoints
+	Port string
+}
+
+// ListenAndServe accepts Gopher connections until the listener errors.
+func (s *GopherServer) ListenAndServe() error {
+	ln, err := net.Listen("tcp", ":"+s.Port)
+	if err != nil {
+		return fmt.Errorf("could not listen on gopher port %s: %w", s.Port, err)
+	}
+	defer ln.Close()
+	fmt.Printf("Gopher server listening on :%s\n", s.Port)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *GopherServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	selector, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	selector = strings.TrimRight(selector, "\r\n")
+
+	host, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+	if host == "" {
+		host = "localhost"
+	}
+
+	switch {
+	case selector == "" || selector == "/":
+		s.writeMenu(conn, host)
+	case selector == "/gary":
+		s.writeImageMenu(conn, host, "gary", s.GaryImages)
+	case selector == "/goober":
+		s.writeImageMenu(conn, host, "goober", s.GooberImages)
+	case selector == "/quote":
+		s.writeLine(conn, s.QuotesStore)
+	case selector == "/joke":
+		s.writeLine(conn, s.JokesStore)
+	case strings.HasPrefix(selector, "/gary/image/"):
+		s.writeImage(conn, s.GaryImages, strings.TrimPrefix(selector, "/gary/image/"))
+	case strings.HasPrefix(selector, "/goober/image/"):
+		s.writeImage(conn, s.GooberImages, strings.TrimPrefix(selector, "/goober/image/"))
+	default:
+		fmt.Fprintf(conn, "3Not found\terror\t%s\t70\r\n.\r\n", host)
+	}
+}
+
+func (s *GopherServer) writeMenu(conn net.Conn, host string) {
+	items := []struct{ display, selector string }{
+		{"A random Gary", "/gary"},
+		{"A random Goober", "/goober"},
+		{"A random quote", "/quote"},
+		{"A random joke", "/joke"},
+	}
+	for _, item := range items {
+		fmt.Fprintf(conn, "1%s\t%s\t%s\t%s\r\n", item.display, item.selector, host, s.Port)
+	}
+	fmt.Fprint(conn, ".\r\n")
+}
+
+func (s *GopherServer) writeImageMenu(conn net.Conn, host, name string, images *core.ImageSet) {
+	imageName := images.Pick(core.SelectRequest{}, "")
+	if imageName == "" {
+		fmt.Fprintf(conn, "3No %s images available\terror\t%s\t70\r\n.\r\n", name, host)
+		return
+	}
+	selector := fmt.Sprintf("/%s/image/%s", name, imageName)
+	fmt.Fprintf(conn, "I%s\t%s\t%s\t%s\r\n.\r\n", imageName, selector, host, s.Port)
+}
+
+func (s *GopherServer) writeLine(conn net.Conn, store *quotes.Store) {
+	if store == nil {
+		fmt.Fprint(conn, "no entries available\r\n")
+		return
+	}
+	entry, err := store.Random("")
+	if err != nil {
+		fmt.Fprintf(conn, "%v\r\n", err)
+		return
+	}
+	fmt.Fprintf(conn, "%s\r\n", entry.Text)
+}
+
+func (s *GopherServer) writeImage(conn net.Conn, images *core.ImageSet, name string) {
+	reader, _, err := images.Open(name)
+	if err != nil {
+		fmt.Fprintf(conn, "could not read %s: %v\r\n", name, err)
+		return
+	}
+	defer reader.Close()
+	_, _ = io.Copy(conn, reader)
+}
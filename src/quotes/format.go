@@ -0,0 +1,101 @@
+package quotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads path and parses it according to its extension: ".json"
+// for a JSON array (of bare strings or {text, tags[]} objects, today's and
+// the new format respectively), ".yaml"/".yml" for an equivalent YAML
+// list, and anything else as newline-delimited plain text.
+func loadFile(path string) ([]Entry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %s: %w", path, err)
+	}
+
+	var entries []Entry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		entries, err = parseJSON(raw)
+	case ".yaml", ".yml":
+		entries, err = parseYAML(raw)
+	default:
+		entries = parseText(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no lines found in %s", path)
+	}
+	return entries, nil
+}
+
+func parseJSON(raw []byte) ([]Entry, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			entries = append(entries, Entry{Text: text})
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(item, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseYAML(raw []byte) ([]Entry, error) {
+	var entries []Entry
+	if err := yaml.Unmarshal(raw, &entries); err == nil {
+		allBlank := true
+		for _, e := range entries {
+			if e.Text != "" {
+				allBlank = false
+				break
+			}
+		}
+		if !allBlank {
+			return entries, nil
+		}
+	}
+
+	var lines []string
+	if err := yaml.Unmarshal(raw, &lines); err != nil {
+		return nil, err
+	}
+	entries = make([]Entry, len(lines))
+	for i, line := range lines {
+		entries[i] = Entry{Text: line}
+	}
+	return entries, nil
+}
+
+func parseText(raw []byte) []Entry {
+	lines := strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n")
+	entries := make([]Entry, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		entries = append(entries, Entry{Text: line})
+	}
+	return entries
+}
@@ -0,0 +1,139 @@
+// Package quotes implements a hot-reloadable store for the quote/joke line
+// files. Unlike a plain read-and-unmarshal-per-request, a Store parses its
+// file once at startup into an atomic.Value and only reparses when
+// fsnotify says the file changed.
+package quotes
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Entry is a single quote/joke. Tags is only populated when the source
+// file stores entries as {text, tags[]} objects rather than bare strings.
+type Entry struct {
+	Text string   `json:"text"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Store holds the parsed entries for one file, hot-reloaded on change.
+type Store struct {
+	path  string
+	value atomic.Value // []Entry
+}
+
+// NewStore loads path (detecting its format from the extension: JSON
+// array, newline-delimited text, or YAML list) and starts watching it for
+// changes.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	s.watch()
+	return s, nil
+}
+
+func (s *Store) reload() error {
+	entries, err := loadFile(s.path)
+	if err != nil {
+		return err
+	}
+	s.value.Store(entries)
+	return nil
+}
+
+func (s *Store) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Failed to create watcher for %s: %v\n", s.path, err)
+		return
+	}
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		fmt.Printf("Failed to watch directory for %s: %v\n", s.path, err)
+		return
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(event.Name) != filepath.Base(s.path) {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					if err := s.reload(); err != nil {
+						fmt.Printf("Failed to reload %s: %v\n", s.path, err)
+						continue
+					}
+					fmt.Printf("Reloaded %s due to event: %s\n", s.path, event)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("Watcher error for %s: %v\n", s.path, err)
+			}
+		}
+	}()
+}
+
+// All returns every entry currently loaded.
+func (s *Store) All() []Entry {
+	return s.value.Load().([]Entry)
+}
+
+// Count returns the number of entries currently loaded.
+func (s *Store) Count() int {
+	return len(s.All())
+}
+
+// Random returns a random entry, optionally restricted to entries tagged
+// with tag (an empty tag matches everything).
+func (s *Store) Random(tag string) (Entry, error) {
+	entries := s.All()
+	if tag != "" {
+		filtered := make([]Entry, 0, len(entries))
+		for _, e := range entries {
+			if hasTag(e, tag) {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	if len(entries) == 0 {
+		if tag != "" {
+			return Entry{}, fmt.Errorf("no entries tagged %q in %s", tag, s.path)
+		}
+		return Entry{}, fmt.Errorf("no entries found in %s", s.path)
+	}
+	return entries[rand.Intn(len(entries))], nil
+}
+
+// ByID returns the entry at the given index, in load order.
+func (s *Store) ByID(id int) (Entry, error) {
+	entries := s.All()
+	if id < 0 || id >= len(entries) {
+		return Entry{}, fmt.Errorf("no entry %d in %s", id, s.path)
+	}
+	return entries[id], nil
+}
+
+func hasTag(e Entry, tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+// Package render implements content negotiation for garyapi's endpoints:
+// picking an output Format from the Accept header or a ?format= override,
+// then rendering a value into that format. Each value type (ImageURL,
+// Line, Count) owns its own Render method, so adding a new format to an
+// existing type - or a new type - is a one-file change.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format is a negotiated output format name.
+type Format string
+
+const (
+	JSON Format = "json"
+	Text Format = "text"
+	HTML Format = "html"
+	Tar  Format = "tar"
+)
+
+var mimeFormats = map[string]Format{
+	"application/json":  JSON,
+	"text/plain":        Text,
+	"text/html":         HTML,
+	"application/x-tar": Tar,
+}
+
+func isKnown(f Format) bool {
+	switch f {
+	case JSON, Text, HTML, Tar:
+		return true
+	default:
+		return false
+	}
+}
+
+// Negotiate picks a Format for c: an explicit ?format= query param wins
+// over the Accept header, which is matched in order against the MIME
+// types above. JSON is the default when neither says anything useful, to
+// match garyapi's historical response shape.
+func Negotiate(c *gin.Context) Format {
+	if raw := c.Query("format"); raw != "" {
+		if f := Format(strings.ToLower(raw)); isKnown(f) {
+			return f
+		}
+	}
+
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mime := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if f, ok := mimeFormats[mime]; ok {
+			return f
+		}
+	}
+	return JSON
+}
+
+// ImageURL is the data behind the /gary and /goober endpoints: a URL
+// pointing at a randomly chosen image, plus the number extracted from its
+// file name.
+type ImageURL struct {
+	URL    string `json:"url"`
+	Number int    `json:"number"`
+}
+
+// Render encodes d in f, returning the response body and its Content-Type.
+func (d ImageURL) Render(f Format) ([]byte, string, error) {
+	switch f {
+	case Text:
+		return []byte(d.URL), "text/plain; charset=utf-8", nil
+	case HTML:
+		body := fmt.Sprintf(`<img src="%s">`, html.EscapeString(d.URL))
+		return []byte(body), "text/html; charset=utf-8", nil
+	case JSON, "":
+		body, err := json.Marshal(d)
+		return body, "application/json", err
+	default:
+		return nil, "", fmt.Errorf("unsupported format %q for an image URL", f)
+	}
+}
+
+// Line is the data behind the /quote and /joke endpoints: a single text
+// entry, marshaled under Key ("quote" or "joke") in JSON mode.
+type Line struct {
+	Key  string
+	Text string
+}
+
+// Render encodes d in f, returning the response body and its Content-Type.
+func (d Line) Render(f Format) ([]byte, string, error) {
+	switch f {
+	case Text:
+		return []byte(d.Text), "text/plain; charset=utf-8", nil
+	case HTML:
+		body := fmt.Sprintf("<blockquote>%s</blockquote>", html.EscapeString(d.Text))
+		return []byte(body), "text/html; charset=utf-8", nil
+	case JSON, "":
+		body, err := json.Marshal(map[string]string{d.Key: d.Text})
+		return body, "application/json", err
+	default:
+		return nil, "", fmt.Errorf("unsupported format %q for a line", f)
+	}
+}
+
+// Count is the data behind the /*/count endpoints.
+type Count struct {
+	Value int
+}
+
+// Render encodes d in f, returning the response body and its Content-Type.
+func (d Count) Render(f Format) ([]byte, string, error) {
+	switch f {
+	case Text:
+		return []byte(strconv.Itoa(d.Value)), "text/plain; charset=utf-8", nil
+	case HTML:
+		body := fmt.Sprintf("<p>%d</p>", d.Value)
+		return []byte(body), "text/html; charset=utf-8", nil
+	case JSON, "":
+		body, err := json.Marshal(map[string]int{"count": d.Value})
+		return body, "application/json", err
+	default:
+		return nil, "", fmt.Errorf("unsupported format %q for a count", f)
+	}
+}
+
+// StatusForError maps a render error to an HTTP status: unsupported
+// formats are the client's fault, everything else is ours.
+func StatusForError(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if strings.HasPrefix(err.Error(), "unsupported format") {
+		return http.StatusNotAcceptable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamTar writes names as a tar archive directly to c's response,
+// opening each entry via open and writing it before moving to the next.
+// When stat reports a size for an entry up front, its content is streamed
+// straight into the archive via io.Copy with no buffering; stat returning
+// ok=false falls back to fully reading the entry first, since a tar
+// header must declare Size before any content bytes are written.
+func StreamTar(c *gin.Context, names []string, open func(name string) (io.ReadCloser, error), stat func(name string) (int64, bool)) error {
+	c.Header("Content-Type", "application/x-tar")
+	c.Status(http.StatusOK)
+
+	tw := tar.NewWriter(c.Writer)
+	for _, name := range names {
+		if err := writeTarEntry(tw, name, open, stat); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, name string, open func(string) (io.ReadCloser, error), stat func(string) (int64, bool)) error {
+	size, ok := stat(name)
+	if !ok {
+		return writeTarEntryBuffered(tw, name, open)
+	}
+
+	reader, err := open(name)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: size, Mode: 0o644}); err != nil {
+		return fmt.Errorf("could not write tar header for %s: %w", name, err)
+	}
+	_, err = io.Copy(tw, reader)
+	return err
+}
+
+// writeTarEntryBuffered fully buffers name's content before writing it, for
+// backends whose Stat can't report a size up front.
+func writeTarEntryBuffered(tw *tar.Writer, name string, open func(string) (io.ReadCloser, error)) error {
+	reader, err := open(name)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", name, err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(data)), Mode: 0o644}); err != nil {
+		return fmt.Errorf("could not write tar header for %s: %w", name, err)
+	}
+	_, err = tw.Write(data)
+	return err
+}